@@ -0,0 +1,173 @@
+// Package canonical implements the RFC 6376 section 3.4 header and body
+// canonicalization algorithms used by DKIM signing and verification.
+package canonical
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// HeaderSimple returns a writer that passes each header field written to it
+// through to w unchanged, per RFC 6376 section 3.4.1. Each Write call must
+// contain exactly one complete "Name:value" header field, folding and all,
+// terminated by CRLF.
+func HeaderSimple(w io.Writer) io.Writer {
+	return headerWriter{w: w, relaxed: false}
+}
+
+// HeaderRelaxed returns a writer that canonicalizes each header field
+// written to it per RFC 6376 section 3.4.2 (unfold continuation lines,
+// collapse runs of WSP to a single space, trim WSP around the value,
+// lowercase the field name) before writing it to w. Each Write call must
+// contain exactly one complete header field, terminated by CRLF.
+func HeaderRelaxed(w io.Writer) io.Writer {
+	return headerWriter{w: w, relaxed: true}
+}
+
+type headerWriter struct {
+	w       io.Writer
+	relaxed bool
+}
+
+func (h headerWriter) Write(p []byte) (int, error) {
+	var out []byte = p
+	if h.relaxed {
+		out = relaxHeader(p)
+	}
+	if _, err := h.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func relaxHeader(field []byte) []byte {
+	idx := bytes.IndexByte(field, ':')
+	if idx < 0 {
+		return field
+	}
+	name := bytes.ToLower(bytes.TrimSpace(field[:idx]))
+	value := RelaxHeaderValue(field[idx+1:])
+
+	var out []byte
+	out = append(out, name...)
+	out = append(out, ':')
+	out = append(out, value...)
+	out = append(out, '\r', '\n')
+	return out
+}
+
+// RelaxHeaderValue applies the value-only portion of the relaxed header
+// canonicalization algorithm: unfold continuation lines, collapse runs of
+// WSP to a single space and trim leading/trailing WSP. It does not touch
+// the header field name, so callers that already split "Name:" from the
+// value (e.g. when re-hashing a header they otherwise leave untouched) can
+// canonicalize just the value.
+func RelaxHeaderValue(value []byte) []byte {
+	// Unfold: a folded continuation line is "CRLF WSP...", remove the CRLF
+	// but keep the WSP, it gets collapsed below.
+	value = bytes.ReplaceAll(value, []byte("\r\n"), []byte(""))
+	value = collapseWSP(value)
+	value = bytes.TrimSpace(value)
+	return value
+}
+
+func collapseWSP(b []byte) []byte {
+	var out []byte
+	var inWSP bool
+	for _, c := range b {
+		if c == ' ' || c == '\t' {
+			if !inWSP {
+				out = append(out, ' ')
+			}
+			inWSP = true
+			continue
+		}
+		inWSP = false
+		out = append(out, c)
+	}
+	return out
+}
+
+// BodySimple returns a WriteCloser that canonicalizes the message body
+// written to it per RFC 6376 section 3.4.3 and writes the result to w on
+// Close. A completely empty body canonicalizes to a single CRLF.
+func BodySimple(w io.Writer) io.WriteCloser {
+	return &bodyWriter{w: w, relaxed: false}
+}
+
+// BodyRelaxed returns a WriteCloser that canonicalizes the message body
+// written to it per RFC 6376 section 3.4.4 and writes the result to w on
+// Close. A completely empty body canonicalizes to the empty string.
+func BodyRelaxed(w io.Writer) io.WriteCloser {
+	return &bodyWriter{w: w, relaxed: true}
+}
+
+// bodyWriter buffers the whole body so that trailing empty lines - which
+// can only be identified once the end of the body is known - are canonicalized
+// correctly on Close.
+type bodyWriter struct {
+	w       io.Writer
+	relaxed bool
+	buf     bytes.Buffer
+}
+
+func (b *bodyWriter) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
+
+func (b *bodyWriter) Close() error {
+	_, err := b.w.Write(CanonicalizeBody(b.buf.Bytes(), b.relaxed))
+	return err
+}
+
+// CanonicalizeBody applies the simple (relaxed=false) or relaxed
+// (relaxed=true) body canonicalization algorithm of RFC 6376 section 3.4
+// to the whole body at once.
+func CanonicalizeBody(body []byte, relaxed bool) []byte {
+	var normalized []byte = normalizeLineEndings(body)
+	var lines []string
+
+	if len(normalized) > 0 {
+		lines = strings.Split(string(normalized), "\n")
+	}
+
+	if relaxed {
+		for i, line := range lines {
+			line = strings.TrimRight(line, " \t")
+			lines[i] = string(collapseWSP([]byte(line)))
+		}
+	}
+
+	// The trailing "" produced by a final line terminator, and any further
+	// trailing "" entries from genuinely empty lines, are all removed: both
+	// canonicalizations ignore empty lines at the end of the body.
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		if relaxed {
+			return []byte{}
+		}
+		// RFC 6376 3.4.3: a completely empty or missing body is
+		// canonicalized as a single CRLF.
+		return []byte("\r\n")
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// normalizeLineEndings rewrites CRLF and bare CR line endings to a single
+// internal LF marker, so that malformed input using bare CR (or bare LF)
+// line endings canonicalizes the same as properly formed CRLF input.
+func normalizeLineEndings(b []byte) []byte {
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	b = bytes.ReplaceAll(b, []byte("\r"), []byte("\n"))
+	return b
+}
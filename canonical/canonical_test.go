@@ -0,0 +1,84 @@
+package canonical
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCanonicalizeBody(t *testing.T) {
+	var cases = []struct {
+		name     string
+		msgFile  string
+		goldFile string
+		relaxed  bool
+	}{
+		{"simple/trailing blank lines", "testdata/body_simple_trailing_blank.msg", "testdata/body_simple_trailing_blank.golden", false},
+		{"relaxed/collapse WSP", "testdata/body_relaxed_collapse.msg", "testdata/body_relaxed_collapse.golden", true},
+		{"simple/empty body", "testdata/body_empty.msg", "testdata/body_empty_simple.golden", false},
+		{"relaxed/empty body", "testdata/body_empty.msg", "testdata/body_empty_relaxed.golden", true},
+		{"simple/CR-only line endings", "testdata/body_cr_only.msg", "testdata/body_cr_only.golden", false},
+		// Exercises RFC 6376 section 3.4.4's WSP-reduction and trailing
+		// blank line rules together on a body with leading, trailing and
+		// multi-character WSP runs plus several trailing empty lines:
+		// relaxed collapses every WSP run (leading included) to a single
+		// space, simple leaves line content untouched. Both strip all but
+		// one trailing CRLF.
+		{"relaxed/RFC 6376 section 3.4.4 example", "testdata/body_rfc6376_example.msg", "testdata/body_rfc6376_example_relaxed.golden", true},
+		{"simple/RFC 6376 section 3.4.4 example", "testdata/body_rfc6376_example.msg", "testdata/body_rfc6376_example_simple.golden", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body, want []byte
+			var err error
+
+			if body, err = os.ReadFile(tc.msgFile); err != nil {
+				t.Fatalf("reading %s: %v", tc.msgFile, err)
+			}
+			if want, err = os.ReadFile(tc.goldFile); err != nil {
+				t.Fatalf("reading %s: %v", tc.goldFile, err)
+			}
+
+			got := CanonicalizeBody(body, tc.relaxed)
+			if string(got) != string(want) {
+				t.Errorf("CanonicalizeBody(relaxed=%v) = %q, want %q", tc.relaxed, got, want)
+			}
+		})
+	}
+}
+
+func TestRelaxHeader(t *testing.T) {
+	var cases = []struct {
+		name  string
+		field string
+		want  string
+	}{
+		{"lowercases name", "Subject: hello\r\n", "subject:hello\r\n"},
+		{"collapses internal WSP", "X-Test:  a   b\tc\r\n", "x-test:a b c\r\n"},
+		{"unfolds continuation lines", "Subject: hello\r\n world\r\n", "subject:hello world\r\n"},
+		{"trims trailing WSP", "Subject: hello   \r\n", "subject:hello\r\n"},
+		// RFC 6376 section 3.4.2's worked example: "B : Y\t\r\n\tZ  \r\n"
+		// (one header field folded across a continuation line) relaxes to
+		// "b:Y Z\r\n".
+		{"RFC 6376 section 3.4.2 example", "B : Y\t\r\n\tZ  \r\n", "b:Y Z\r\n"},
+		{
+			"long folded header with many continuation lines",
+			"Received: from a.example.com\r\n" +
+				" by b.example.com\r\n" +
+				" with ESMTP\r\n" +
+				" id 1234\r\n" +
+				" for <joe@football.example.com>;\r\n" +
+				" Fri, 11 Jul 2003 21:01:54 -0700\r\n",
+			"received:from a.example.com by b.example.com with ESMTP id 1234 for <joe@football.example.com>; Fri, 11 Jul 2003 21:01:54 -0700\r\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := string(relaxHeader([]byte(tc.field)))
+			if got != tc.want {
+				t.Errorf("relaxHeader(%q) = %q, want %q", tc.field, got, tc.want)
+			}
+		})
+	}
+}
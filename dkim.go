@@ -15,6 +15,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/mschneider82/dkim/canonical"
 )
 
 var (
@@ -45,6 +47,8 @@ func (Dkim *DKIM) GetHasher() crypto.Hash {
 		return crypto.SHA1
 	case "rsa-sha256":
 		return crypto.SHA256
+	case "ed25519-sha256":
+		return crypto.SHA256
 	default:
 		return crypto.SHA256 // TODO return 0 seems to be good alternative; needs further investigation
 	}
@@ -106,7 +110,7 @@ func (Dkim *DKIMHeader) String() string {
 }
 
 func (Dkim *DKIM) parseDKIM() (err error) {
-	var prev_key, key, value, item string
+	var key, value, item string
 	var kvs []string
 	var header string
 
@@ -126,7 +130,7 @@ func (Dkim *DKIM) parseDKIM() (err error) {
 			Dkim.Header.Version = value
 		case "a":
 			Dkim.Header.Algorithm = value
-			if value != "rsa-sha1" && value != "rsa-sha256" {
+			if value != "rsa-sha1" && value != "rsa-sha256" && value != "ed25519-sha256" {
 				return ErrUnsupportedAlgorithm
 			}
 		case "b":
@@ -144,12 +148,9 @@ func (Dkim *DKIM) parseDKIM() (err error) {
 		case "d":
 			Dkim.Header.Domain = value
 		case "h":
-			for _, key := range strings.Split(value, ":") {
-				if prev_key != key {
-					Dkim.Header.Headers = append(Dkim.Header.Headers, key)
-					prev_key = key
-				}
-			}
+			// Repeats are significant (oversigning, RFC 6376 section 5.4/3.7)
+			// and must be preserved in order, not deduplicated.
+			Dkim.Header.Headers = strings.Split(value, ":")
 		case "i":
 			Dkim.Header.Identifier = value
 		case "l":
@@ -197,34 +198,57 @@ func (Dkim *DKIM) dkimSignatureForHash() []byte {
 	return rx.ReplaceAll([]byte(header.Get(Dkim.HeaderName)), []byte("b="))
 }
 
+// canonizeHeader canonicalizes a single header field's value per RFC 6376
+// section 3.4, delegating to the canonical subpackage. It operates on the
+// value only; the caller writes the (possibly lowercased) field name and
+// colon separately.
 func (Dkim *DKIM) canonizeHeader(body []byte) []byte {
 	if Dkim.IsHeaderRelaxed {
-		if len(body) == 0 {
-			return nil
-		}
-		rx := regexp.MustCompile(`[ \t]+`)
-		body = rx.ReplaceAll(body, []byte(" "))
-		rx2 := regexp.MustCompile(` \r\n`)
-		body = rx2.ReplaceAll(body, []byte("\r\n"))
-	} else {
-		if len(body) == 0 {
-			return []byte("")
-		}
+		return canonical.RelaxHeaderValue(body)
+	}
+	if len(body) == 0 {
+		return []byte("")
 	}
 	return body
+}
 
+// dkimHeaderInstance identifies one occurrence of a DKIM-Signature (or
+// compatible) header among possibly several on the same message.
+type dkimHeaderInstance struct {
+	name  string
+	value string
 }
 
-func findDkimHeader(mail_header mail.Header) (string, error) {
-	var headers []string = []string{"DKIM-Signature", "X-Google-DKIM-Signature"}
-	var header string
+// findDkimHeaders returns every signature header found on mail_header, in
+// the order they appear, across all of the header names DKIM signatures
+// are recognized under.
+func findDkimHeaders(mail_header mail.Header) []dkimHeaderInstance {
+	var headerNames []string = []string{"DKIM-Signature", "X-Google-DKIM-Signature"}
+	var instances []dkimHeaderInstance
 
-	for _, header = range headers {
-		if mail_header.Get(header) != "" {
-			return header, nil
+	for _, name := range headerNames {
+		for _, value := range mail_header[textproto.CanonicalMIMEHeaderKey(name)] {
+			instances = append(instances, dkimHeaderInstance{name: name, value: value})
 		}
 	}
-	return "", errors.New("not found")
+	return instances
+}
+
+// withSingleHeader returns a shallow copy of msg whose header only carries
+// the given single value for name, so the existing single-header DKIM
+// parser can be reused unmodified against one specific signature out of
+// several on the same message.
+func withSingleHeader(msg *mail.Message, name string, value string) *mail.Message {
+	var header mail.Header = make(mail.Header, len(msg.Header))
+	var key string
+	var values []string
+
+	for key, values = range msg.Header {
+		header[key] = values
+	}
+	header[textproto.CanonicalMIMEHeaderKey(name)] = []string{value}
+
+	return &mail.Message{Header: header, Body: msg.Body}
 }
 
 func getRawHeaders(r *bufio.Reader) (mail.Header, *bufio.Reader) {
@@ -264,13 +288,19 @@ func getRawHeaders(r *bufio.Reader) (mail.Header, *bufio.Reader) {
 	return headers, bufio.NewReader(io.MultiReader(bufio.NewReader(readed), r))
 }
 
+// GetHeaderHash canonicalizes and hashes the headers named in h=, in the
+// order they're listed there. Per RFC 6376 section 5.4/3.7, a repeated name
+// in h= oversigns it: the first occurrence consumes the bottom-most actual
+// instance of that header field, the second occurrence the next one up, and
+// so on; once the real instances are exhausted, the empty string is hashed
+// so an attacker can't add a same-named header the signer never saw.
 func (Dkim *DKIM) GetHeaderHash() []byte {
 	var hasher hash.Hash = Dkim.GetHasher().New()
 	var header_key string
 	var header_value string
-	var header_values []string
 	var dkim_sig_key string
 	var header mail.Header
+	var consumed map[string]int = make(map[string]int)
 
 	if Dkim.headerHash != nil {
 		return Dkim.headerHash
@@ -291,12 +321,16 @@ func (Dkim *DKIM) GetHeaderHash() []byte {
 		} else {
 			header_key = key
 		}
-		header_values = header[textproto.CanonicalMIMEHeaderKey(key)]
-		// Skip header
-		if len(header_values) == 0 {
-			continue
+		var canonicalKey string = textproto.CanonicalMIMEHeaderKey(key)
+		var header_values []string = header[canonicalKey]
+		var pos int = len(header_values) - 1 - consumed[canonicalKey]
+		consumed[canonicalKey]++
+
+		if pos >= 0 {
+			header_value = header_values[pos]
+		} else {
+			header_value = ""
 		}
-		header_value = header_values[len(header_values)-1]
 		hasher.Write([]byte(header_key + ":"))
 		hasher.Write(Dkim.canonizeHeader([]byte(header_value)))
 		if Dkim.IsHeaderRelaxed {
@@ -309,53 +343,22 @@ func (Dkim *DKIM) GetHeaderHash() []byte {
 	return Dkim.headerHash
 }
 
+// readBodyTo canonicalizes Dkim.Mail.Body per RFC 6376 section 3.4 and
+// writes the result to w, delegating to the canonical subpackage. When
+// Header.Length (l=) is set and shorter than the canonicalized body, only
+// its first Length octets are written, per RFC 6376 section 3.7.
 func (Dkim *DKIM) readBodyTo(w io.Writer) {
-	var line, prev_line, tmp []byte
-	var r *bufio.Reader = bufio.NewReader(Dkim.Mail.Body)
+	var body []byte
 	var err error
 
-	for {
-		line, err = r.ReadBytes('\n')
-		if err != nil {
-			break
-		}
-		if len(prev_line) > 0 {
-			if len(tmp) > 0 {
-				w.Write(tmp)
-				tmp = nil
-			}
-			w.Write(prev_line)
-			prev_line = nil
-		}
-		if Dkim.IsBodyRelaxed {
-			if len(line) > 2 {
-				line = bytes.TrimRight(line, "\t\r\n ") // remove WSP on the right side and trunk \r\n
-				if len(line) > 0 {
-					line = bytes.Replace(line, []byte("\t"), []byte(" "), -1)          // replace all \t to \s
-					line = regexp.MustCompile(`[ ]{2,}`).ReplaceAll(line, []byte(" ")) // replace double \s to one
-					if line[0] == ' ' {                                                // if the first symbol is \s, may be next too, replace all \s to one
-						line = append([]byte(" "), bytes.TrimLeft(line, " ")...)
-					}
-				}
-				line = append(line, '\r', '\n')
-			}
-			if len(line) > 2 {
-				prev_line = line
-			} else {
-				tmp = append(tmp, '\r', '\n')
-			}
-		} else {
-			prev_line = line
-		}
+	if body, err = io.ReadAll(Dkim.Mail.Body); err != nil && err != io.EOF {
+		return
 	}
-	if len(prev_line) > 0 {
-		if !bytes.Equal(prev_line, []byte("\r\n")) {
-			if len(tmp) > 0 {
-				w.Write(tmp)
-			}
-			w.Write(prev_line)
-		}
+	var canonicalized []byte = canonical.CanonicalizeBody(body, Dkim.IsBodyRelaxed)
+	if Dkim.Header.Length > 0 && Dkim.Header.Length < len(canonicalized) {
+		canonicalized = canonicalized[:Dkim.Header.Length]
 	}
+	w.Write(canonicalized)
 }
 
 func (Dkim *DKIM) GetBodyHash() []byte {
@@ -0,0 +1,71 @@
+// Package keygen generates DKIM signing keypairs and the DNS TXT records
+// operators need to publish them, so a new selector can be stood up
+// end-to-end without reaching for openssl and hand-building the record.
+package keygen
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/mschneider82/dkim"
+)
+
+// GenerateRSAKey generates an RSA keypair of the given bit size and returns
+// its PEM-encoded PKCS#1 private key alongside the DKIM TXT record value to
+// publish for it, already chunked into BIND-compatible 255-byte quoted
+// segments (see dkim.ChunkTXTRecord) and ready to paste into a zone file.
+func GenerateRSAKey(bits int) (privateKeyPEM []byte, publicKeyRecord []string, err error) {
+	var key *rsa.PrivateKey
+	var der []byte
+
+	if key, err = rsa.GenerateKey(rand.Reader, bits); err != nil {
+		return nil, nil, err
+	}
+	privateKeyPEM = pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	if der, err = x509.MarshalPKIXPublicKey(&key.PublicKey); err != nil {
+		return nil, nil, err
+	}
+	return privateKeyPEM, dkim.ChunkTXTRecord(txtRecord("rsa", der)), nil
+}
+
+// GenerateEd25519Key generates an Ed25519 keypair and returns its
+// PEM-encoded PKCS#8 private key alongside the DKIM TXT record value to
+// publish for it, already chunked into BIND-compatible 255-byte quoted
+// segments (see dkim.ChunkTXTRecord) and ready to paste into a zone file.
+func GenerateEd25519Key() (privateKeyPEM []byte, publicKeyRecord []string, err error) {
+	var pub ed25519.PublicKey
+	var priv ed25519.PrivateKey
+	var pkcs8, der []byte
+
+	if pub, priv, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		return nil, nil, err
+	}
+	if pkcs8, err = x509.MarshalPKCS8PrivateKey(priv); err != nil {
+		return nil, nil, err
+	}
+	privateKeyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})
+	if der, err = x509.MarshalPKIXPublicKey(pub); err != nil {
+		return nil, nil, err
+	}
+	return privateKeyPEM, dkim.ChunkTXTRecord(txtRecord("ed25519", der)), nil
+}
+
+func txtRecord(kind string, der []byte) string {
+	return fmt.Sprintf("v=DKIM1; k=%s; p=%s", kind, base64.StdEncoding.EncodeToString(der))
+}
+
+// LoadPrivateKey accepts PEM-encoded PKCS#1, PKCS#8 or Ed25519 key bytes,
+// as produced by GenerateRSAKey/GenerateEd25519Key, and returns a
+// crypto.Signer usable as dkim.SignerOptions.PrivateKey.
+func LoadPrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	return dkim.LoadPrivateKey(pemBytes)
+}
@@ -0,0 +1,92 @@
+package keygen
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/mschneider82/dkim"
+)
+
+const testRawMessage = "From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.com>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"\r\n" +
+	"Hi.\r\n"
+
+// fakeResolver answers LookupTXT with a fixed, in-memory record set so the
+// round-trip test below doesn't touch the network.
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupTXT(_ context.Context, domain string) ([]string, error) {
+	if txt, ok := f[domain]; ok {
+		return txt, nil
+	}
+	return nil, errNotFound(domain)
+}
+
+type errNotFound string
+
+func (e errNotFound) Error() string { return "no such TXT record: " + string(e) }
+
+// testRoundTrip generates a key with generate, loads it back via
+// LoadPrivateKey, signs a message with it and verifies the signature
+// against the record published record, exercising the full
+// Generate -> LoadPrivateKey -> Sign -> Verify path.
+func testRoundTrip(t *testing.T, algorithm string, generate func() ([]byte, []string, error)) {
+	t.Helper()
+
+	privateKeyPEM, record, err := generate()
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	signer, err := LoadPrivateKey(privateKeyPEM)
+	if err != nil {
+		t.Fatalf("LoadPrivateKey: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(testRawMessage)))
+	if err != nil {
+		t.Fatalf("parsing test message: %v", err)
+	}
+	sig, err := dkim.Sign(msg, dkim.SignerOptions{
+		PrivateKey:       signer,
+		Domain:           "football.example.com",
+		Selector:         "brisbane",
+		Algorithm:        algorithm,
+		Canonicalization: "relaxed/relaxed",
+		Headers:          []string{"From", "To", "Subject"},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	signed, err := mail.ReadMessage(bufio.NewReader(io.MultiReader(
+		strings.NewReader(string(sig)), strings.NewReader(testRawMessage))))
+	if err != nil {
+		t.Fatalf("parsing signed message: %v", err)
+	}
+
+	resolver := fakeResolver{
+		"brisbane._domainkey.football.example.com.": record,
+	}
+
+	results := dkim.VerifyAll(context.Background(), signed, resolver)
+	if len(results) != 1 || results[0].Status != dkim.SUCCESS {
+		t.Fatalf("expected successful verification, got %+v", results)
+	}
+}
+
+func TestRSAKeyRoundTrip(t *testing.T) {
+	testRoundTrip(t, "rsa-sha256", func() ([]byte, []string, error) {
+		return GenerateRSAKey(2048)
+	})
+}
+
+func TestEd25519KeyRoundTrip(t *testing.T) {
+	testRoundTrip(t, "ed25519-sha256", GenerateEd25519Key)
+}
@@ -0,0 +1,117 @@
+package dkim
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+)
+
+// VerificationStatus is the outcome of verifying a single DKIM-Signature.
+type VerificationStatus int
+
+const (
+	// NOTSIGNED means the message carried no recognizable signature header.
+	NOTSIGNED VerificationStatus = iota
+	// SUCCESS means the signature verified.
+	SUCCESS
+	// PERMFAIL means the signature is definitely bad (malformed header,
+	// revoked/missing key, bad body or header hash) and retrying will not help.
+	PERMFAIL
+	// TEMPFAIL means the signature could not be evaluated because of a
+	// transient problem (DNS lookup failure, temporary key-retrieval error)
+	// and the caller may want to retry later.
+	TEMPFAIL
+)
+
+func (status VerificationStatus) String() string {
+	switch status {
+	case SUCCESS:
+		return "SUCCESS"
+	case PERMFAIL:
+		return "PERMFAIL"
+	case TEMPFAIL:
+		return "TEMPFAIL"
+	default:
+		return "NOTSIGNED"
+	}
+}
+
+// VerificationResult reports the outcome of verifying one DKIM-Signature
+// header found on a message.
+type VerificationResult struct {
+	Domain           string
+	Selector         string
+	Algorithm        string
+	Canonicalization string
+	Status           VerificationStatus
+	Reason           string
+	Err              error
+}
+
+// VerifyAll verifies every DKIM-Signature (and compatible) header present
+// on msg and reports one VerificationResult per signature, in the order
+// the headers appear. Messages with no signature header yield a single
+// NOTSIGNED result. ctx bounds the DNS lookups used to fetch each signer's
+// public key; resolver is the DNS backend to use (nil uses DefaultResolver).
+func VerifyAll(ctx context.Context, msg *mail.Message, resolver Resolver) []VerificationResult {
+	var instances []dkimHeaderInstance = findDkimHeaders(msg.Header)
+	var results []VerificationResult
+
+	if len(instances) == 0 {
+		return []VerificationResult{{Status: NOTSIGNED, Reason: "no DKIM-Signature header found"}}
+	}
+
+	for _, instance := range instances {
+		results = append(results, verifyInstance(ctx, msg, instance, resolver))
+	}
+	return results
+}
+
+func verifyInstance(ctx context.Context, msg *mail.Message, instance dkimHeaderInstance, resolver Resolver) VerificationResult {
+	var shadow *mail.Message = withSingleHeader(msg, instance.name, instance.value)
+	var dkim *DKIM
+	var err error
+
+	if dkim, err = NewDKIM(instance.name, shadow); err != nil {
+		return VerificationResult{Status: PERMFAIL, Reason: err.Error(), Err: err}
+	}
+
+	var result VerificationResult = VerificationResult{
+		Domain:           dkim.Header.Domain,
+		Selector:         dkim.Header.Selector,
+		Algorithm:        dkim.Header.Algorithm,
+		Canonicalization: dkim.Header.Canonization,
+	}
+
+	if dkim.Verify(ctx, resolver) {
+		result.Status = SUCCESS
+		return result
+	}
+
+	if _, err = dkim.GetPublicKey(ctx, resolver); err != nil {
+		result.Err = err
+		result.Reason = err.Error()
+		if errors.Is(err, ErrDNSLookupFailed) {
+			result.Status = TEMPFAIL
+		} else {
+			result.Status = PERMFAIL
+		}
+		return result
+	}
+
+	result.Status = PERMFAIL
+	result.Reason = "signature or body hash mismatch"
+	return result
+}
+
+// Verify reports whether msg carries at least one DKIM-Signature that
+// verifies successfully. It is a thin wrapper around VerifyAll for callers
+// that only need a pass/fail answer.
+func Verify(ctx context.Context, msg *mail.Message, resolver Resolver) bool {
+	for _, result := range VerifyAll(ctx, msg, resolver) {
+		if result.Status == SUCCESS {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package dkim
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// TestVerifyAllStatuses signs one message and verifies it against four
+// different resolvers, covering all four terminal VerifyAll outcomes: a
+// resolver serving the matching key (SUCCESS), one with no record at all for
+// the domain (PERMFAIL, via the ErrNoRecord sentinel fakeResolver's "not
+// found" error wraps), one reporting a genuinely transient DNS problem
+// (TEMPFAIL), and one serving an explicitly revoked key, i.e. an empty p=
+// (PERMFAIL).
+func TestVerifyAllStatuses(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshaling public key: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(testRawMessage)))
+	if err != nil {
+		t.Fatalf("parsing test message: %v", err)
+	}
+	sig, err := Sign(msg, SignerOptions{
+		PrivateKey:       key,
+		Domain:           "football.example.com",
+		Selector:         "brisbane",
+		Algorithm:        "rsa-sha256",
+		Canonicalization: "relaxed/relaxed",
+		Headers:          []string{"From", "To", "Subject"},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	const recordName = "brisbane._domainkey.football.example.com."
+
+	tests := []struct {
+		name     string
+		resolver Resolver
+		want     VerificationStatus
+	}{
+		{
+			name: "matching key succeeds",
+			resolver: fakeResolver{
+				recordName: {"v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)},
+			},
+			want: SUCCESS,
+		},
+		{
+			name:     "missing record is a permfail",
+			resolver: fakeResolver{},
+			want:     PERMFAIL,
+		},
+		{
+			name:     "transient lookup error is a tempfail",
+			resolver: transientResolver{},
+			want:     TEMPFAIL,
+		},
+		{
+			name: "revoked key is a permfail",
+			resolver: fakeResolver{
+				recordName: {"v=DKIM1; k=rsa; p="},
+			},
+			want: PERMFAIL,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			signed, err := mail.ReadMessage(bufio.NewReader(io.MultiReader(
+				strings.NewReader(string(sig)), strings.NewReader(testRawMessage))))
+			if err != nil {
+				t.Fatalf("parsing signed message: %v", err)
+			}
+
+			results := VerifyAll(context.Background(), signed, tc.resolver)
+			if len(results) != 1 {
+				t.Fatalf("expected 1 result, got %d: %+v", len(results), results)
+			}
+			if results[0].Status != tc.want {
+				t.Fatalf("expected %v, got %v (%s)", tc.want, results[0].Status, results[0].Reason)
+			}
+		})
+	}
+}
+
+// transientResolver always fails with a plain error unrelated to
+// ErrNoRecord, simulating a transient DNS problem (timeout, SERVFAIL) that
+// callers should retry rather than treat as a permanent failure.
+type transientResolver struct{}
+
+func (transientResolver) LookupTXT(_ context.Context, domain string) ([]string, error) {
+	return nil, errTransient(domain)
+}
@@ -0,0 +1,363 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingPrivateKey = errors.New("signer options: PrivateKey is required")
+	ErrMissingDomain     = errors.New("signer options: Domain is required")
+	ErrMissingSelector   = errors.New("signer options: Selector is required")
+	ErrMissingHeaders    = errors.New("signer options: Headers is required")
+	ErrInvalidPrivateKey = errors.New("signer options: PrivateKey could not be parsed")
+)
+
+// SignerOptions configures a single DKIM-Signature to be produced by Sign.
+type SignerOptions struct {
+	// PrivateKey accepts either PEM-encoded bytes ([]byte) or an already
+	// parsed crypto.Signer (e.g. *rsa.PrivateKey, ed25519.PrivateKey).
+	PrivateKey interface{}
+
+	Domain   string // d=
+	Selector string // s=
+	AUID     string // i=
+
+	// Algorithm is one of "rsa-sha1", "rsa-sha256" or "ed25519-sha256".
+	// Defaults to "rsa-sha256" when empty.
+	Algorithm string
+
+	// Canonicalization is "simple/simple", "relaxed/relaxed",
+	// "relaxed/simple" or "simple/relaxed". Defaults to "relaxed/relaxed".
+	Canonicalization string
+
+	// Headers lists the header field names to sign, in order. Repeat a
+	// name to oversign it (protects against a header being added later).
+	Headers []string
+
+	BodyLength        int   // l=, 0 means "whole body"
+	SignatureExpireIn int64 // x= is set to the effective SignatureTimestamp+SignatureExpireIn when > 0
+
+	// SignatureTimestamp is t=. Defaults to 0 (omitted) when not set, except
+	// when SignatureExpireIn is also set: then it defaults to time.Now()
+	// so that x= is a sensible absolute expiry rather than ExpireIn seconds
+	// after the Unix epoch.
+	SignatureTimestamp int64
+}
+
+// Signer produces a DKIM-Signature header for a message using the given
+// SignerOptions. Create one with NewSigner and reuse it across messages
+// signed with the same key and options.
+type Signer struct {
+	opts   SignerOptions
+	signer crypto.Signer
+	hasher crypto.Hash
+}
+
+// NewSigner validates opts, parses the private key and returns a reusable
+// Signer.
+func NewSigner(opts SignerOptions) (*Signer, error) {
+	var err error
+	var signer Signer = Signer{opts: opts}
+
+	if opts.Domain == "" {
+		return nil, ErrMissingDomain
+	}
+	if opts.Selector == "" {
+		return nil, ErrMissingSelector
+	}
+	if len(opts.Headers) == 0 {
+		return nil, ErrMissingHeaders
+	}
+	if opts.PrivateKey == nil {
+		return nil, ErrMissingPrivateKey
+	}
+
+	if signer.opts.Algorithm == "" {
+		signer.opts.Algorithm = "rsa-sha256"
+	}
+	if signer.opts.Canonicalization == "" {
+		signer.opts.Canonicalization = "relaxed/relaxed"
+	}
+
+	if signer.hasher, err = algorithmHash(signer.opts.Algorithm); err != nil {
+		return nil, err
+	}
+
+	if signer.signer, err = LoadPrivateKey(opts.PrivateKey); err != nil {
+		return nil, err
+	}
+
+	return &signer, nil
+}
+
+// LoadPrivateKey accepts either PEM-encoded bytes or an already parsed
+// crypto.Signer and returns a crypto.Signer usable for DKIM signing.
+func LoadPrivateKey(key interface{}) (crypto.Signer, error) {
+	switch k := key.(type) {
+	case crypto.Signer:
+		return k, nil
+	case []byte:
+		return parsePrivateKeyPEM(k)
+	default:
+		return nil, ErrInvalidPrivateKey
+	}
+}
+
+func parsePrivateKeyPEM(der []byte) (crypto.Signer, error) {
+	var block *pem.Block
+	var rest []byte
+	var err error
+	var key interface{}
+
+	block, rest = pem.Decode(der)
+	if block == nil {
+		_ = rest
+		return nil, ErrInvalidPrivateKey
+	}
+
+	if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key.(*rsa.PrivateKey), nil
+	}
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		case crypto.Signer:
+			return k, nil
+		}
+	}
+	return nil, ErrInvalidPrivateKey
+}
+
+func algorithmHash(algorithm string) (crypto.Hash, error) {
+	switch algorithm {
+	case "rsa-sha1":
+		return crypto.SHA1, nil
+	case "rsa-sha256":
+		return crypto.SHA256, nil
+	case "ed25519-sha256":
+		return crypto.SHA256, nil
+	default:
+		return 0, ErrUnsupportedAlgorithm
+	}
+}
+
+// Sign signs msg and returns a single "DKIM-Signature: ...\r\n" header,
+// folded at 70 columns, ready to be prepended to the message.
+func (s *Signer) Sign(msg *mail.Message) ([]byte, error) {
+	var dkim *DKIM = s.signingDKIM(msg)
+	var bodyHash []byte = dkim.GetBodyHash()
+	var partial string = s.partialSignature(bodyHash)
+	var headerHash []byte
+	var signature []byte
+	var err error
+
+	headerHash = s.headerHashFor(dkim, partial)
+
+	if signature, err = s.sign(headerHash); err != nil {
+		return nil, err
+	}
+
+	var value string = partial + base64.StdEncoding.EncodeToString(signature)
+	var folded string = foldHeader("DKIM-Signature: "+value, 70)
+	return []byte(folded + "\r\n"), nil
+}
+
+// Sign is a convenience wrapper around NewSigner(opts).Sign(msg).
+func Sign(msg *mail.Message, opts SignerOptions) ([]byte, error) {
+	var signer *Signer
+	var err error
+
+	if signer, err = NewSigner(opts); err != nil {
+		return nil, err
+	}
+	return signer.Sign(msg)
+}
+
+// signingDKIM builds a *DKIM populated just enough to reuse canonizeHeader
+// and readBodyTo for canonicalization during signing.
+func (s *Signer) signingDKIM(msg *mail.Message) *DKIM {
+	var isRelaxedHeader bool = strings.HasPrefix(s.opts.Canonicalization, "relaxed")
+	var isRelaxedBody bool = strings.HasSuffix(s.opts.Canonicalization, "/relaxed")
+
+	return &DKIM{
+		Mail:            msg,
+		HeaderName:      "DKIM-Signature",
+		RawMailHeader:   msg.Header,
+		Header:          &DKIMHeader{Headers: s.opts.Headers, Length: s.opts.BodyLength},
+		IsHeaderRelaxed: isRelaxedHeader,
+		IsBodyRelaxed:   isRelaxedBody,
+	}
+}
+
+// effectiveTimestamp returns the t= value to sign with: the caller's
+// SignatureTimestamp if set, otherwise the current time when
+// SignatureExpireIn is set (so x= is computed relative to "now" instead of
+// the Unix epoch), otherwise 0 (t= omitted).
+func (s *Signer) effectiveTimestamp() int64 {
+	if s.opts.SignatureTimestamp > 0 {
+		return s.opts.SignatureTimestamp
+	}
+	if s.opts.SignatureExpireIn > 0 {
+		return time.Now().Unix()
+	}
+	return 0
+}
+
+// partialSignature renders the DKIM-Signature tag list with an empty b=,
+// as required by RFC 6376 section 3.5 while computing the signature.
+func (s *Signer) partialSignature(bodyHash []byte) string {
+	var tags []string
+
+	tags = append(tags, "v=1")
+	tags = append(tags, "a="+s.opts.Algorithm)
+	tags = append(tags, "c="+s.opts.Canonicalization)
+	tags = append(tags, "d="+s.opts.Domain)
+	tags = append(tags, "s="+s.opts.Selector)
+	var timestamp int64 = s.effectiveTimestamp()
+	if timestamp > 0 {
+		tags = append(tags, "t="+strconv.FormatInt(timestamp, 10))
+	}
+	if s.opts.SignatureExpireIn > 0 {
+		tags = append(tags, "x="+strconv.FormatInt(timestamp+s.opts.SignatureExpireIn, 10))
+	}
+	if s.opts.AUID != "" {
+		tags = append(tags, "i="+s.opts.AUID)
+	}
+	if s.opts.BodyLength > 0 {
+		tags = append(tags, "l="+strconv.Itoa(s.opts.BodyLength))
+	}
+	tags = append(tags, "h="+strings.Join(s.opts.Headers, ":"))
+	tags = append(tags, "bh="+base64.StdEncoding.EncodeToString(bodyHash))
+	tags = append(tags, "b=")
+
+	return strings.Join(tags, "; ")
+}
+
+// headerHashFor canonicalizes the signed headers plus the partial
+// DKIM-Signature value and returns the resulting hash, reusing
+// canonizeHeader the same way GetHeaderHash does when verifying.
+//
+// Per RFC 6376 section 5.4/3.7, repeating a name in Headers oversigns it:
+// the first occurrence in Headers consumes the bottom-most instance of
+// that header field, the second occurrence the next one up, and so on;
+// once the real instances are exhausted, the empty string is signed so an
+// attacker can't add a same-named header the signer never saw.
+func (s *Signer) headerHashFor(dkim *DKIM, partial string) []byte {
+	var hasher = s.hasher.New()
+	var headerKey, headerValue string
+	var consumed map[string]int = make(map[string]int)
+
+	for _, key := range s.opts.Headers {
+		var canonicalKey string = textproto.CanonicalMIMEHeaderKey(key)
+		var values []string = dkim.Mail.Header[canonicalKey]
+		var pos int = len(values) - 1 - consumed[canonicalKey]
+		consumed[canonicalKey]++
+
+		if pos >= 0 {
+			headerValue = values[pos]
+		} else {
+			headerValue = ""
+		}
+
+		if dkim.IsHeaderRelaxed {
+			headerKey = strings.ToLower(key)
+		} else {
+			headerKey = key
+		}
+		hasher.Write([]byte(headerKey + ":"))
+		hasher.Write(dkim.canonizeHeader([]byte(headerValue)))
+		if dkim.IsHeaderRelaxed {
+			hasher.Write([]byte("\r\n"))
+		}
+	}
+
+	if dkim.IsHeaderRelaxed {
+		hasher.Write([]byte("dkim-signature:"))
+	} else {
+		hasher.Write([]byte("DKIM-Signature:"))
+	}
+	hasher.Write(dkim.canonizeHeader([]byte(" " + partial)))
+
+	return hasher.Sum(nil)
+}
+
+func (s *Signer) sign(headerHash []byte) ([]byte, error) {
+	switch s.opts.Algorithm {
+	case "ed25519-sha256":
+		key, ok := s.signer.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("signer: ed25519-sha256 requires an ed25519.PrivateKey, got %T", s.signer)
+		}
+		return ed25519.Sign(key, headerHash), nil
+	default:
+		return s.signer.Sign(rand.Reader, headerHash, s.hasher)
+	}
+}
+
+// foldHeader folds a raw "Name: value" header at width columns using
+// folding white space (FWS), so the result is safe to insert verbatim
+// into a message. Tags are first grouped onto lines at "; " boundaries;
+// any single tag that is still too long to fit on a line by itself (most
+// commonly b= or bh=) is then hard-wrapped into width-sized chunks, since
+// RFC 6376 permits FWS anywhere within a tag's value.
+func foldHeader(raw string, width int) string {
+	var parts []string = strings.Split(raw, "; ")
+	var lines []string
+	var current string = parts[0]
+
+	for _, part := range parts[1:] {
+		if len(current)+2+len(part) > width {
+			lines = append(lines, current+";")
+			current = part
+		} else {
+			current = current + "; " + part
+		}
+	}
+	lines = append(lines, current)
+
+	var wrapped []string
+	for _, line := range lines {
+		wrapped = append(wrapped, wrapLine(line, width, len(wrapped) == 0)...)
+	}
+
+	return strings.Join(wrapped, "\r\n\t")
+}
+
+// wrapLine hard-wraps a single line with no "; " breakpoints of its own
+// into width-sized chunks when it doesn't fit on one line. Every physical
+// line except the very first one in the whole folded header (first==true)
+// is written with a leading "\t" continuation marker, so only width-1
+// columns of content are actually available there; budget accounts for
+// that so no emitted line, tab included, exceeds width.
+func wrapLine(line string, width int, first bool) []string {
+	var budget int = width
+	if !first {
+		budget = width - 1
+	}
+	if len(line) <= budget {
+		return []string{line}
+	}
+	var chunks []string
+	for len(line) > budget {
+		chunks = append(chunks, line[:budget])
+		line = line[budget:]
+		budget = width - 1
+	}
+	return append(chunks, line)
+}
@@ -0,0 +1,235 @@
+package dkim
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRawMessage = "Received: hop one\r\n" +
+	"Received: hop two\r\n" +
+	"From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.com>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"\r\n" +
+	"Hi.\r\n"
+
+func signAndParse(t *testing.T, opts SignerOptions) (*mail.Message, []byte) {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(testRawMessage)))
+	if err != nil {
+		t.Fatalf("parsing test message: %v", err)
+	}
+
+	sig, err := Sign(msg, opts)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	signed, err := mail.ReadMessage(bufio.NewReader(io.MultiReader(
+		strings.NewReader(string(sig)), strings.NewReader(testRawMessage))))
+	if err != nil {
+		t.Fatalf("parsing signed message: %v", err)
+	}
+	return signed, sig
+}
+
+func verifyWithKey(t *testing.T, signed *mail.Message, selector, domain string, der []byte, kind string) []VerificationResult {
+	t.Helper()
+
+	resolver := fakeResolver{
+		selector + "._domainkey." + domain + ".": {
+			"v=DKIM1; k=" + kind + "; p=" + base64.StdEncoding.EncodeToString(der),
+		},
+	}
+	return VerifyAll(context.Background(), signed, resolver)
+}
+
+// TestSignVerifyRoundTrip exercises Sign end to end with an oversigned
+// header (two real "Received" instances plus a third, nonexistent, slot in
+// h=) and checks the result verifies successfully via the public API.
+func TestSignVerifyRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshaling public key: %v", err)
+	}
+
+	opts := SignerOptions{
+		PrivateKey:       key,
+		Domain:           "football.example.com",
+		Selector:         "brisbane",
+		Algorithm:        "rsa-sha256",
+		Canonicalization: "relaxed/relaxed",
+		Headers:          []string{"Received", "Received", "Received", "From", "To", "Subject"},
+	}
+	signed, _ := signAndParse(t, opts)
+
+	results := verifyWithKey(t, signed, "brisbane", "football.example.com", der, "rsa")
+	if len(results) != 1 || results[0].Status != SUCCESS {
+		t.Fatalf("expected successful verification, got %+v", results)
+	}
+}
+
+// TestSignExpireInDefaultsTimestampToNow covers the t=/x= fix: when only
+// SignatureExpireIn is set, t= must default to roughly now rather than 0, so
+// x=t+SignatureExpireIn is a sensible absolute expiry.
+func TestSignExpireInDefaultsTimestampToNow(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+
+	opts := SignerOptions{
+		PrivateKey:        key,
+		Domain:            "football.example.com",
+		Selector:          "brisbane",
+		Canonicalization:  "relaxed/relaxed",
+		Headers:           []string{"From", "To", "Subject"},
+		SignatureExpireIn: 7 * 24 * 60 * 60,
+	}
+	_, sig := signAndParse(t, opts)
+
+	header := strings.ReplaceAll(strings.ReplaceAll(string(sig), "\r\n", ""), "\t", "")
+
+	tMatch := regexp.MustCompile(`t=(\d+)`).FindStringSubmatch(header)
+	xMatch := regexp.MustCompile(`x=(\d+)`).FindStringSubmatch(header)
+	if tMatch == nil || xMatch == nil {
+		t.Fatalf("expected both t= and x= tags in signature, got %q", header)
+	}
+
+	tVal, err := strconv.ParseInt(tMatch[1], 10, 64)
+	if err != nil {
+		t.Fatalf("parsing t=: %v", err)
+	}
+	xVal, err := strconv.ParseInt(xMatch[1], 10, 64)
+	if err != nil {
+		t.Fatalf("parsing x=: %v", err)
+	}
+
+	now := time.Now().Unix()
+	if tVal < now-5 || tVal > now+5 {
+		t.Fatalf("t= should be close to now (%d), got %d", now, tVal)
+	}
+	if xVal-tVal != 7*24*60*60 {
+		t.Fatalf("x=-t= should be 7 days, got %d", xVal-tVal)
+	}
+}
+
+// TestSignFoldsLongLines covers the 70-column fold promised by Sign's doc
+// comment: an RSA-2048 signature's b= tag is hundreds of base64 characters
+// and, unlike the shorter tags, doesn't naturally fit on one line after
+// "; "-boundary folding alone, so foldHeader must also hard-wrap it.
+func TestSignFoldsLongLines(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+
+	opts := SignerOptions{
+		PrivateKey:       key,
+		Domain:           "football.example.com",
+		Selector:         "brisbane",
+		Algorithm:        "rsa-sha256",
+		Canonicalization: "relaxed/relaxed",
+		Headers:          []string{"Received", "From", "To", "Subject"},
+	}
+	_, sig := signAndParse(t, opts)
+
+	const width = 70
+	for _, line := range strings.Split(strings.TrimRight(string(sig), "\r\n"), "\r\n") {
+		if len(line) > width {
+			t.Fatalf("line exceeds %d columns including its leading tab (%d): %q", width, len(line), line)
+		}
+	}
+}
+
+// TestBodyLengthTruncation covers the l=/BodyLength fix: RFC 6376 section
+// 3.7 says a signer publishing l= commits only to the first l= octets of
+// the canonicalized body, so content appended after that point (e.g. a
+// mailing-list footer) must not affect bh= and must not break verification.
+func TestBodyLengthTruncation(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshaling public key: %v", err)
+	}
+
+	const rawA = "From: Joe SixPack <joe@football.example.com>\r\n" +
+		"To: Suzie Q <suzie@shopping.example.com>\r\n" +
+		"Subject: Is dinner ready?\r\n" +
+		"\r\n" +
+		"Hi.\r\nbody A trailer\r\n"
+	const rawB = "From: Joe SixPack <joe@football.example.com>\r\n" +
+		"To: Suzie Q <suzie@shopping.example.com>\r\n" +
+		"Subject: Is dinner ready?\r\n" +
+		"\r\n" +
+		"Hi.\r\nsomething completely different\r\n"
+
+	opts := SignerOptions{
+		PrivateKey:       key,
+		Domain:           "football.example.com",
+		Selector:         "brisbane",
+		Algorithm:        "rsa-sha256",
+		Canonicalization: "relaxed/relaxed",
+		Headers:          []string{"From", "To", "Subject"},
+		BodyLength:       5, // "Hi.\r\n" canonicalizes to 5 octets
+	}
+
+	bhOf := func(raw string) string {
+		msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(raw)))
+		if err != nil {
+			t.Fatalf("parsing test message: %v", err)
+		}
+		sig, err := Sign(msg, opts)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		m := regexp.MustCompile(`bh=([^;\s]+)`).FindStringSubmatch(
+			strings.ReplaceAll(strings.ReplaceAll(string(sig), "\r\n", ""), "\t", ""))
+		if m == nil {
+			t.Fatalf("no bh= tag found in %q", sig)
+		}
+		return m[1]
+	}
+
+	if bhA, bhB := bhOf(rawA), bhOf(rawB); bhA != bhB {
+		t.Fatalf("bh= should be identical when bodies agree within BodyLength, got %q vs %q", bhA, bhB)
+	}
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(rawA)))
+	if err != nil {
+		t.Fatalf("parsing test message: %v", err)
+	}
+	sig, err := Sign(msg, opts)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	signed, err := mail.ReadMessage(bufio.NewReader(io.MultiReader(
+		strings.NewReader(string(sig)), strings.NewReader(rawB))))
+	if err != nil {
+		t.Fatalf("parsing signed message: %v", err)
+	}
+
+	results := verifyWithKey(t, signed, "brisbane", "football.example.com", der, "rsa")
+	if len(results) != 1 || results[0].Status != SUCCESS {
+		t.Fatalf("expected the signature to survive a body change past l=, got %+v", results)
+	}
+}
@@ -0,0 +1,78 @@
+package dkim
+
+import (
+	"errors"
+	"net/mail"
+)
+
+// DKIMHeader holds the parsed tag=value pairs of a single DKIM-Signature
+// header field. The `dkim` struct tag names the tag each field serializes
+// to/from (see (*DKIMHeader).String and parseDKIM).
+type DKIMHeader struct {
+	Version       string            `dkim:"v"`
+	Algorithm     string            `dkim:"a"`
+	Signature     []byte            `dkim:"b"`
+	BodyHash      []byte            `dkim:"bh"`
+	Canonization  string            `dkim:"c"`
+	Domain        string            `dkim:"d"`
+	Headers       []string          `dkim:"h"`
+	Identifier    string            `dkim:"i"`
+	Length        int               `dkim:"l"`
+	QueryType     string            `dkim:"q"`
+	Selector      string            `dkim:"s"`
+	Unixtime      int               `dkim:"t"`
+	Expiration    int               `dkim:"x"`
+	CopiedHeaders map[string]string `dkim:"z"`
+}
+
+// Status records the outcome of the individual checks Verify performs.
+type Status struct {
+	HasPublicKey bool
+	ValidBody    bool
+	Valid        bool
+}
+
+// DKIM represents a single DKIM-Signature header together with the message
+// it was found on (or is being produced for), and the cached results of
+// canonicalizing and verifying it.
+type DKIM struct {
+	Mail          *mail.Message
+	HeaderName    string
+	Header        *DKIMHeader
+	RawMailHeader mail.Header
+
+	IsHeaderRelaxed bool
+	IsBodyRelaxed   bool
+
+	PublicKey *DKIMPublicKey
+	Status    Status
+
+	headerHash []byte
+	bodyHash   []byte
+}
+
+// Handlers lets callers plug in their own DNS fetch and public-key caching
+// strategy. CacheGetHandler returning a non-nil error is treated as a cache
+// miss, triggering a DNS lookup via DefaultResolver (or Resolver, see
+// GetPublicKey).
+type Handlers struct {
+	DnsFetchHandler func(domain string) ([]byte, error)
+	CacheGetHandler func(domain string) ([]byte, error)
+	CacheSetHandler func(domain string, value []byte) error
+}
+
+// CustomHandlers is used by GetPublicKey for key caching. The zero-value
+// handlers below always miss the cache and discard cache writes, so
+// GetPublicKey falls through to DefaultResolver unless a caller overrides
+// them with a real cache.
+var CustomHandlers = Handlers{
+	DnsFetchHandler: func(domain string) ([]byte, error) {
+		return nil, errors.New("dkim: DnsFetchHandler not configured")
+	},
+	CacheGetHandler: func(domain string) ([]byte, error) {
+		return nil, errors.New("dkim: cache miss")
+	},
+	CacheSetHandler: func(domain string, value []byte) error {
+		return nil
+	},
+}
@@ -2,18 +2,125 @@ package dkim
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"net"
 	"strings"
 )
 
+var (
+	// ErrKeyRevoked is returned when the signer published an empty p= tag,
+	// which RFC 6376 section 3.6.1 defines as an explicitly revoked key.
+	ErrKeyRevoked = errors.New("dkim: public key revoked")
+	// ErrNoRecord is returned when the _domainkey TXT record does not exist
+	// (NXDOMAIN) or contains no usable DKIM key record. This is a permanent
+	// failure: retrying will not produce a different answer.
+	//
+	// A custom Resolver signals this same condition by returning ErrNoRecord
+	// (or an error wrapping it, via errors.Is) from LookupTXT — GetPublicKey
+	// treats that identically to a *net.DNSError with IsNotFound set, since
+	// not every backend (a cache, a DoH client, a test double) can produce a
+	// *net.DNSError of its own.
+	ErrNoRecord = errors.New("dkim: no DKIM key record found")
+	// ErrDNSLookupFailed is returned for transient DNS problems (timeouts,
+	// SERVFAIL, connection errors). Callers should treat this as retryable.
+	ErrDNSLookupFailed = errors.New("dkim: DNS lookup failed")
+	// ErrServiceTypeMismatch is returned when the key record's s= tag
+	// restricts it to services other than email.
+	ErrServiceTypeMismatch = errors.New("dkim: key record is not valid for email (s=)")
+)
+
+// Resolver looks up TXT records, so the DNS backend used for DKIM key
+// retrieval can be swapped out (tests, custom timeouts, caching resolvers).
+// A Resolver that knows a domain has no record at all (as opposed to a
+// transient failure) should report it by returning an error satisfying
+// errors.Is(err, ErrNoRecord).
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+type netResolver struct{}
+
+func (netResolver) LookupTXT(ctx context.Context, domain string) ([]string, error) {
+	txt, err := net.DefaultResolver.LookupTXT(ctx, domain)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNoRecord, domain)
+	}
+	return txt, err
+}
+
+// DefaultResolver is the Resolver used by GetPublicKey, Verify and VerifyAll
+// when none is given explicitly. It is backed by net.DefaultResolver.
+var DefaultResolver Resolver = netResolver{}
+
 type DKIMPublicKey struct {
 	Key       string `dkim_pk:"k", json:"key"`
 	Version   string `dkim_pk:"v", json:"version"`
 	Tag       string `dkim_pk:"t", json:"version"`
 	PublicKey []byte `dkim_pk:"p", json:"public_key"`
+
+	// ParsedKey holds the result of parsing PublicKey, either
+	// *rsa.PublicKey or ed25519.PublicKey depending on Key ("rsa"/"ed25519").
+	ParsedKey crypto.PublicKey `json:"-"`
+
+	// ServiceType is the s= tag, e.g. "email" or "*". Empty means
+	// unrestricted.
+	ServiceType string
+	// Testing is true when the t= tag contains the "y" flag, meaning the
+	// domain is testing DKIM and verification failures should not be
+	// treated as a hard failure by policy-aware callers.
+	Testing bool
+	// Revoked is true when p= was present but empty, per RFC 6376 3.6.1.
+	Revoked bool
+}
+
+// maxTXTChunk is the largest substring BIND (and most other nameservers)
+// accept inside a single quoted TXT segment.
+const maxTXTChunk = 255
+
+// ChunkTXTRecord splits a DNS TXT record value into substrings of at most
+// 255 bytes, the limit most nameservers (BIND included) enforce per quoted
+// segment of a TXT record.
+func ChunkTXTRecord(record string) []string {
+	var chunks []string
+
+	for len(record) > maxTXTChunk {
+		chunks = append(chunks, record[:maxTXTChunk])
+		record = record[maxTXTChunk:]
+	}
+	return append(chunks, record)
+}
+
+// PublishRecord renders the DNS zone-file line an operator would publish
+// for this key under selector._domainkey.domain, the inverse of the TXT
+// record parsing done by newDKIMPublicKey/GetPublicKey. The value is
+// chunked into BIND-compatible 255-byte quoted segments, so the result is
+// ready to paste into a zone file as-is.
+func (pk *DKIMPublicKey) PublishRecord(selector, domain string) string {
+	var version string = pk.Version
+	if version == "" {
+		version = "DKIM1"
+	}
+	var kind string = pk.Key
+	if kind == "" {
+		kind = "rsa"
+	}
+
+	var value string = fmt.Sprintf("v=%s; k=%s; p=%s", version, kind, base64.StdEncoding.EncodeToString(pk.PublicKey))
+	var chunks []string = ChunkTXTRecord(value)
+	var quoted []string = make([]string, len(chunks))
+	for i, chunk := range chunks {
+		quoted[i] = "\"" + chunk + "\""
+	}
+
+	return fmt.Sprintf("%s._domainkey.%s. IN TXT ( %s )", selector, domain, strings.Join(quoted, " "))
 }
 
 func newDKIMPublicKey(txt string) (*DKIMPublicKey, error) {
@@ -21,6 +128,7 @@ func newDKIMPublicKey(txt string) (*DKIMPublicKey, error) {
 	var kvs []string
 	var err error
 	var dkim_pk DKIMPublicKey
+	var sawP bool
 	for _, item = range strings.Split(strings.Replace(txt, " ", "", -1), ";") {
 		kvs = strings.SplitN(item, "=", 2)
 		if len(kvs) != 2 {
@@ -40,8 +148,21 @@ func newDKIMPublicKey(txt string) (*DKIMPublicKey, error) {
 			break
 		case "t":
 			dkim_pk.Tag = value
+			for _, flag := range strings.Split(value, ":") {
+				if flag == "y" {
+					dkim_pk.Testing = true
+				}
+			}
+			break
+		case "s":
+			dkim_pk.ServiceType = value
 			break
 		case "p":
+			sawP = true
+			if value == "" {
+				dkim_pk.PublicKey = []byte{}
+				break
+			}
 			if dkim_pk.PublicKey, err = base64.StdEncoding.DecodeString(value); err != nil {
 				return nil, errors.New("invalid public")
 			}
@@ -50,48 +171,98 @@ func newDKIMPublicKey(txt string) (*DKIMPublicKey, error) {
 			//     return nil, errors.New(fmt.Sprintf("unknown key %s", key))
 		}
 	}
-	if dkim_pk.PublicKey == nil {
+	if !sawP {
 		return nil, errors.New("empty")
 	}
+	if len(dkim_pk.PublicKey) == 0 {
+		dkim_pk.Revoked = true
+	}
 	return &dkim_pk, nil
 }
-func (Dkim *DKIM) GetPublicKey() (*DKIMPublicKey, error) {
+
+// GetPublicKey fetches and parses the DKIM key record for Dkim.Header's
+// selector/domain, using ctx for the lookup and resolver as the DNS
+// backend. A nil resolver uses DefaultResolver.
+func (Dkim *DKIM) GetPublicKey(ctx context.Context, resolver Resolver) (*DKIMPublicKey, error) {
 	var domain string = Dkim.Header.Selector + "._domainkey." + Dkim.Header.Domain + "."
 	var err error
 	var public_key []byte
 	var dkim_pk *DKIMPublicKey
 
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+
 	if Dkim.PublicKey != nil {
 		return Dkim.PublicKey, nil
 	}
-	public_key, err = CustomHandlers.CacheGetHandler(domain)
 
+	public_key, err = CustomHandlers.CacheGetHandler(domain)
 	if err != nil {
-		if public_key, err = CustomHandlers.DnsFetchHandler(domain); err == nil {
-			CustomHandlers.CacheSetHandler(domain, public_key)
+		var txt []string
+		if txt, err = resolver.LookupTXT(ctx, domain); err != nil {
+			var dnsErr *net.DNSError
+			if errors.Is(err, ErrNoRecord) || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+				return nil, fmt.Errorf("%w: %s", ErrNoRecord, domain)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrDNSLookupFailed, err)
+		}
+		if len(txt) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNoRecord, domain)
 		}
+		// A resolver may split a TXT record across multiple strings; RFC
+		// 6376 3.6.2.2 requires they be concatenated before parsing.
+		public_key = []byte(strings.Join(txt, ""))
+		CustomHandlers.CacheSetHandler(domain, public_key)
+	}
+
+	if dkim_pk, err = newDKIMPublicKey(string(public_key)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoRecord, err)
+	}
+	if dkim_pk.Revoked {
+		return nil, ErrKeyRevoked
 	}
-	if err == nil {
-		if dkim_pk, err = newDKIMPublicKey(string(public_key)); err != nil {
-			return nil, err
+	if dkim_pk.ServiceType != "" && dkim_pk.ServiceType != "*" {
+		var allowed bool
+		for _, svc := range strings.Split(dkim_pk.ServiceType, ":") {
+			if svc == "email" {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return nil, ErrServiceTypeMismatch
 		}
-		Dkim.Status.HasPublicKey = true
-		return dkim_pk, nil
 	}
-	return nil, errors.New("no public key found")
+	if dkim_pk.ParsedKey, err = x509.ParsePKIXPublicKey(dkim_pk.PublicKey); err != nil {
+		return nil, err
+	}
+
+	Dkim.Status.HasPublicKey = true
+	Dkim.PublicKey = dkim_pk
+	return dkim_pk, nil
 }
 
-func (Dkim *DKIM) Verify() bool {
+// Verify checks the body hash and signature of Dkim against its published
+// public key, fetched using ctx and resolver (nil uses DefaultResolver).
+func (Dkim *DKIM) Verify(ctx context.Context, resolver Resolver) bool {
 	var err error
-	var pk interface{}
 
 	if Dkim.Header.Domain != "" {
 		Dkim.Status.ValidBody = bytes.Equal(Dkim.GetBodyHash(), Dkim.Header.BodyHash)
-		if Dkim.PublicKey, err = Dkim.GetPublicKey(); err == nil {
+		if Dkim.PublicKey, err = Dkim.GetPublicKey(ctx, resolver); err == nil {
 
-			if pk, err = x509.ParsePKIXPublicKey(Dkim.PublicKey.PublicKey); err == nil {
-				if err = rsa.VerifyPKCS1v15(pk.(*rsa.PublicKey), Dkim.GetHasher(), Dkim.GetHeaderHash(), Dkim.Header.Signature); err == nil {
-					Dkim.Status.Valid = true
+			switch {
+			case Dkim.Header.Algorithm == "ed25519-sha256" || Dkim.PublicKey.Key == "ed25519":
+				if pub, ok := Dkim.PublicKey.ParsedKey.(ed25519.PublicKey); ok {
+					if ed25519.Verify(pub, Dkim.GetHeaderHash(), Dkim.Header.Signature) {
+						Dkim.Status.Valid = true
+					}
+				}
+			default:
+				if pub, ok := Dkim.PublicKey.ParsedKey.(*rsa.PublicKey); ok {
+					if err = rsa.VerifyPKCS1v15(pub, Dkim.GetHasher(), Dkim.GetHeaderHash(), Dkim.Header.Signature); err == nil {
+						Dkim.Status.Valid = true
+					}
 				}
 			}
 		}